@@ -0,0 +1,46 @@
+package logbucket
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/honeycombio/honeyelb/meta"
+)
+
+type ALBDownloader struct {
+	Prefix, BucketName, AccountID, Region, LBName string
+}
+
+func NewALBDownloader(cfg aws.Config, bucketName, bucketPrefix, lbName string) *ALBDownloader {
+	metadata := meta.Data(cfg)
+	return &ALBDownloader{
+		AccountID:  metadata.AccountID,
+		Region:     metadata.Region,
+		BucketName: bucketName,
+		Prefix:     bucketPrefix,
+		LBName:     lbName,
+	}
+}
+
+// pass in time.Now().UTC()
+func (d *ALBDownloader) ObjectPrefix(day time.Time) string {
+	dayPath := day.Format("/2006/01/02")
+	// ALB/NLB access logs are delivered under the same "elasticloadbalancing"
+	// directory and filename prefix as classic ELB (AWSApplicationLoadBalancing
+	// / "elasticloadbalancingv2" never appears in the S3 key); "_app." is what
+	// actually distinguishes an ALB key from a classic ELB one.
+	return d.Prefix + "/AWSLogs/" + d.AccountID + "/" + AWSElasticLoadBalancing + "/" + d.Region + dayPath +
+		"/" + d.AccountID + "_" + AWSElasticLoadBalancing + "_" + d.Region + "_app." + d.LBName
+}
+
+func (d *ALBDownloader) String() string {
+	return d.LBName
+}
+
+func (d *ALBDownloader) Bucket() string {
+	return d.BucketName
+}
+
+func (d *ALBDownloader) Format() LogFormat {
+	return LogFormatALB
+}
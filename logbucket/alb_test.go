@@ -0,0 +1,29 @@
+package logbucket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestALBDownloader_ObjectPrefix(t *testing.T) {
+	d := &ALBDownloader{
+		Prefix:     "my-prefix",
+		BucketName: "my-bucket",
+		AccountID:  "123456789012",
+		Region:     "us-east-1",
+		LBName:     "my-lb",
+	}
+
+	day := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	want := "my-prefix/AWSLogs/123456789012/elasticloadbalancing/us-east-1/2020/01/02/123456789012_elasticloadbalancing_us-east-1_app.my-lb"
+	if got := d.ObjectPrefix(day); got != want {
+		t.Errorf("ObjectPrefix() = %q, want %q", got, want)
+	}
+}
+
+func TestALBDownloader_Format(t *testing.T) {
+	d := &ALBDownloader{}
+	if got := d.Format(); got != LogFormatALB {
+		t.Errorf("Format() = %q, want %q", got, LogFormatALB)
+	}
+}
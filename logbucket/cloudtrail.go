@@ -0,0 +1,45 @@
+package logbucket
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/honeycombio/honeyelb/meta"
+)
+
+type CloudTrailDownloader struct {
+	Prefix, BucketName, AccountID, Region string
+}
+
+func NewCloudTrailDownloader(cfg aws.Config, bucketName, bucketPrefix string) *CloudTrailDownloader {
+	metadata := meta.Data(cfg)
+	return &CloudTrailDownloader{
+		AccountID:  metadata.AccountID,
+		Region:     metadata.Region,
+		BucketName: bucketName,
+		Prefix:     bucketPrefix,
+	}
+}
+
+// pass in time.Now().UTC()
+func (d *CloudTrailDownloader) ObjectPrefix(day time.Time) string {
+	dayPath := day.Format("/2006/01/02")
+	// AWS writes the directory segment as "CloudTrail", not the lowercase
+	// AWSCloudTrail constant (which identifies the service elsewhere, e.g.
+	// as this downloader's String() entity name) - S3 prefixes are
+	// case-sensitive, so this must
+	// match exactly or every list call returns zero objects.
+	return d.Prefix + "/AWSLogs/" + d.AccountID + "/CloudTrail/" + d.Region + dayPath + "/"
+}
+
+func (d *CloudTrailDownloader) String() string {
+	return AWSCloudTrail
+}
+
+func (d *CloudTrailDownloader) Bucket() string {
+	return d.BucketName
+}
+
+func (d *CloudTrailDownloader) Format() LogFormat {
+	return LogFormatCloudTrail
+}
@@ -0,0 +1,28 @@
+package logbucket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCloudTrailDownloader_ObjectPrefix(t *testing.T) {
+	d := &CloudTrailDownloader{
+		Prefix:     "my-prefix",
+		BucketName: "my-bucket",
+		AccountID:  "123456789012",
+		Region:     "us-east-1",
+	}
+
+	day := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	want := "my-prefix/AWSLogs/123456789012/CloudTrail/us-east-1/2020/01/02/"
+	if got := d.ObjectPrefix(day); got != want {
+		t.Errorf("ObjectPrefix() = %q, want %q", got, want)
+	}
+}
+
+func TestCloudTrailDownloader_Format(t *testing.T) {
+	d := &CloudTrailDownloader{}
+	if got := d.Format(); got != LogFormatCloudTrail {
+		t.Errorf("Format() = %q, want %q", got, LogFormatCloudTrail)
+	}
+}
@@ -0,0 +1,109 @@
+package logbucket
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/honeycombio/honeyelb/state"
+)
+
+// Config describes how to build the AWS config a Downloader talks to S3
+// (and, for EventDownloader, SQS) with. It replaces constructing an
+// aws.Config by hand at the call site, so that callers running on EC2/EKS,
+// assuming a cross-account role, or pointing at an S3-compatible store for
+// testing all go through the same path.
+type Config struct {
+	// Region is the AWS region to use. Required unless Profile or the
+	// environment/shared config already supplies one.
+	Region string
+
+	// Profile selects a named profile from the shared AWS config/credentials
+	// files. Leave empty to use the default credential chain (environment,
+	// shared config, EC2/ECS instance role).
+	Profile string
+
+	// AssumeRoleARN, if set, is assumed via STS on top of whatever base
+	// credentials are resolved (static, profile, or instance role). This is
+	// the common case for customers who deliver ELB/CloudTrail logs into a
+	// central logging account.
+	AssumeRoleARN string
+
+	// ExternalID is passed along with AssumeRoleARN when the role's trust
+	// policy requires one.
+	ExternalID string
+
+	// AccessKeyID, SecretAccessKey, and SessionToken configure a static
+	// credential provider. Leave all empty to fall back to the default
+	// credential chain (which includes EC2 instance-profile credentials).
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// EndpointOverride points the S3 client at a non-AWS endpoint, e.g. a
+	// MinIO, Ceph, or LocalStack instance used for testing. See
+	// Downloader.EndpointOverride for where this is actually applied.
+	EndpointOverride string
+
+	// S3ForcePathStyle forces path-style addressing (bucket as part of the
+	// URL path rather than a subdomain), which most S3-compatible stores
+	// require.
+	S3ForcePathStyle bool
+}
+
+// NewConfig resolves an aws.Config from c, in the following order: static
+// keys, if provided; otherwise the default credential chain (environment,
+// shared config/profile, EC2/ECS instance role). If AssumeRoleARN is set,
+// the resolved credentials are used to assume that role via STS.
+func (c Config) NewConfig(ctx context.Context) (aws.Config, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+
+	if c.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(c.Region))
+	}
+	if c.Profile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(c.Profile))
+	}
+	if c.AccessKeyID != "" || c.SecretAccessKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(c.AccessKeyID, c.SecretAccessKey, c.SessionToken),
+		))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("Error loading AWS config: %s", err)
+	}
+
+	if c.AssumeRoleARN != "" {
+		stsClient := sts.NewFromConfig(cfg)
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, c.AssumeRoleARN, func(o *stscreds.AssumeRoleOptions) {
+			if c.ExternalID != "" {
+				o.ExternalID = aws.String(c.ExternalID)
+			}
+		}))
+	}
+
+	return cfg, nil
+}
+
+// NewDownloader resolves c via NewConfig and builds a Downloader from the
+// result, carrying c's EndpointOverride and S3ForcePathStyle through onto
+// the returned Downloader so callers pointing at MinIO/Ceph/LocalStack get
+// them applied automatically instead of having to re-read those two fields
+// off c themselves.
+func (c Config) NewDownloader(ctx context.Context, stater state.Stater, downloader ObjectDownloader, concurrency int) (*Downloader, error) {
+	cfg, err := c.NewConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewDownloader(cfg, stater, downloader, concurrency)
+	d.EndpointOverride = c.EndpointOverride
+	d.S3ForcePathStyle = c.S3ForcePathStyle
+	return d, nil
+}
@@ -0,0 +1,81 @@
+package logbucket
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestNewConfig_StaticCredentials(t *testing.T) {
+	c := Config{
+		Region:          "us-west-2",
+		AccessKeyID:     "AKIDTEST",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+	}
+
+	cfg, err := c.NewConfig(context.Background())
+	if err != nil {
+		t.Fatalf("NewConfig returned error: %s", err)
+	}
+
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve returned error: %s", err)
+	}
+	if creds.AccessKeyID != c.AccessKeyID {
+		t.Errorf("AccessKeyID = %q, want %q", creds.AccessKeyID, c.AccessKeyID)
+	}
+	if creds.SecretAccessKey != c.SecretAccessKey {
+		t.Errorf("SecretAccessKey = %q, want %q", creds.SecretAccessKey, c.SecretAccessKey)
+	}
+	if creds.SessionToken != c.SessionToken {
+		t.Errorf("SessionToken = %q, want %q", creds.SessionToken, c.SessionToken)
+	}
+	if cfg.Region != c.Region {
+		t.Errorf("Region = %q, want %q", cfg.Region, c.Region)
+	}
+}
+
+func TestNewConfig_AssumeRoleWrapsCredentials(t *testing.T) {
+	c := Config{
+		Region:          "us-east-1",
+		AccessKeyID:     "AKIDTEST",
+		SecretAccessKey: "secret",
+		AssumeRoleARN:   "arn:aws:iam::123456789012:role/cross-account-logging",
+		ExternalID:      "ext-id",
+	}
+
+	cfg, err := c.NewConfig(context.Background())
+	if err != nil {
+		t.Fatalf("NewConfig returned error: %s", err)
+	}
+
+	if _, ok := cfg.Credentials.(*aws.CredentialsCache); !ok {
+		t.Fatalf("Credentials = %T, want *aws.CredentialsCache wrapping the AssumeRole provider", cfg.Credentials)
+	}
+}
+
+func TestConfig_NewDownloader_CarriesEndpointOverrideAndForcePathStyle(t *testing.T) {
+	c := Config{
+		Region:           "us-east-1",
+		EndpointOverride: "http://localhost:9000",
+		S3ForcePathStyle: true,
+	}
+
+	d, err := c.NewDownloader(context.Background(), nil, &ELBDownloader{}, 3)
+	if err != nil {
+		t.Fatalf("NewDownloader returned error: %s", err)
+	}
+
+	if d.EndpointOverride != c.EndpointOverride {
+		t.Errorf("EndpointOverride = %q, want %q", d.EndpointOverride, c.EndpointOverride)
+	}
+	if !d.S3ForcePathStyle {
+		t.Error("S3ForcePathStyle = false, want true")
+	}
+	if d.Concurrency != 3 {
+		t.Errorf("Concurrency = %d, want 3", d.Concurrency)
+	}
+}
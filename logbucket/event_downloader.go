@@ -0,0 +1,250 @@
+package logbucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/honeycombio/honeyelb/state"
+)
+
+// EventDownloader wraps a Downloader, sourcing objects to download from S3
+// event notifications delivered over an SQS queue (optionally wrapped in an
+// SNS envelope) rather than polling the bucket on a timer.
+type EventDownloader struct {
+	*Downloader
+
+	sqsClient *sqs.Client
+	QueueURL  string
+
+	// VisibilityTimeout is passed along with each ReceiveMessage call, in
+	// seconds, so that a message being processed isn't redelivered to
+	// another consumer before downloadObject has a chance to finish.
+	VisibilityTimeout int32
+
+	// MaxMessages is the maximum number of messages to request per
+	// ReceiveMessage call (SQS caps this at 10).
+	MaxMessages int32
+
+	// WaitTimeSeconds controls how long each ReceiveMessage call long-polls
+	// for before returning empty, to avoid hammering SQS when idle.
+	WaitTimeSeconds int32
+
+	// Downloaded is the channel EventDownloader's own Download() returns;
+	// it mirrors Downloader.DownloadedObjects but only after DeleteMessage
+	// has been attempted for the originating SQS message (a failed delete is
+	// logged and the object is still forwarded).
+	Downloaded chan state.DownloadedObject
+
+	// pending maps an object key to the SQS message that announced it, so
+	// the message can be deleted once downloadObject reports success.
+	pending   map[string]sqstypes.Message
+	pendingMu sync.Mutex
+}
+
+// s3EventNotification mirrors the subset of the S3 event notification JSON
+// schema (http://docs.aws.amazon.com/AmazonS3/latest/dev/notification-content-structure.html)
+// that we care about.
+type s3EventNotification struct {
+	Records []struct {
+		S3 struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key string `json:"key"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// snsEnvelope is the shape SQS messages take when the queue is subscribed to
+// an SNS topic rather than receiving S3 notifications directly.
+type snsEnvelope struct {
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// NewEventDownloader builds an EventDownloader that pulls S3 object
+// notifications for queueURL instead of listing bucketName on a timer.
+// concurrency is forwarded to NewDownloader, bounding how many messages are
+// downloaded at once; visibilityTimeout, maxMessages, and waitTimeSeconds are
+// passed to ReceiveMessage so operators can tune throughput. A
+// visibilityTimeout of 0 leaves the queue's own setting in effect; a
+// maxMessages of 0 or less becomes 10 and a waitTimeSeconds of 0 or less
+// becomes 20.
+func NewEventDownloader(cfg aws.Config, stater state.Stater, downloader ObjectDownloader, queueURL string, concurrency int, visibilityTimeout, maxMessages, waitTimeSeconds int32) *EventDownloader {
+	if maxMessages <= 0 {
+		maxMessages = 10
+	}
+	if waitTimeSeconds <= 0 {
+		waitTimeSeconds = 20
+	}
+
+	return &EventDownloader{
+		Downloader:        NewDownloader(cfg, stater, downloader, concurrency),
+		sqsClient:         sqs.NewFromConfig(cfg),
+		QueueURL:          queueURL,
+		VisibilityTimeout: visibilityTimeout,
+		MaxMessages:       maxMessages,
+		WaitTimeSeconds:   waitTimeSeconds,
+		Downloaded:        make(chan state.DownloadedObject),
+		pending:           make(map[string]sqstypes.Message),
+	}
+}
+
+// parseS3Event extracts the bucket/key pairs referenced by a single S3 event
+// notification body, which may be an SNS envelope wrapping the real
+// notification. The returned objects are synthetic: S3 doesn't tell us size
+// or last-modified time in the notification itself, so Size and
+// LastModified are filled with placeholders (0 and now) since downloadObject
+// logs both unconditionally.
+func parseS3Event(body string) ([]*types.Object, error) {
+	var envelope snsEnvelope
+	if err := json.Unmarshal([]byte(body), &envelope); err == nil && envelope.Message != "" {
+		body = envelope.Message
+	}
+
+	var notification s3EventNotification
+	if err := json.Unmarshal([]byte(body), &notification); err != nil {
+		return nil, fmt.Errorf("Error unmarshaling S3 event notification: %s", err)
+	}
+
+	objs := make([]*types.Object, 0, len(notification.Records))
+	for _, record := range notification.Records {
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Error unescaping object key %q: %s", record.S3.Object.Key, err)
+		}
+		objs = append(objs, &types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(0),
+			LastModified: aws.Time(time.Now()),
+		})
+	}
+
+	return objs, nil
+}
+
+// alreadyProcessed reports whether key is present in the Stater's record of
+// processed objects, which doubles as our idempotency check for messages
+// redelivered from a DLQ.
+func (d *EventDownloader) alreadyProcessed(key string) bool {
+	processedObjects, err := d.ProcessedObjects()
+	if err != nil {
+		logrus.Error(err)
+		return false
+	}
+
+	for _, processedObj := range processedObjects {
+		if processedObj == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// trackPending records which SQS message announced key, so ackDownloads can
+// find it again once the object has been downloaded.
+func (d *EventDownloader) trackPending(key string, msg sqstypes.Message) {
+	d.pendingMu.Lock()
+	d.pending[key] = msg
+	d.pendingMu.Unlock()
+}
+
+// takePending removes and returns the SQS message associated with key, if
+// any is still pending.
+func (d *EventDownloader) takePending(key string) (sqstypes.Message, bool) {
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	msg, ok := d.pending[key]
+	if ok {
+		delete(d.pending, key)
+	}
+	return msg, ok
+}
+
+// consumeQueue long-polls QueueURL for S3 event notifications, enqueuing a
+// synthetic *types.Object on ObjectsToDownload for each record so it flows
+// through the same worker pool and retry logic as polled downloads. The
+// originating message is only deleted once downloadObject succeeds (see
+// ackDownloads), so a crash mid-flight leaves the message to be redelivered
+// rather than lost.
+func (d *EventDownloader) consumeQueue() {
+	ctx := context.Background()
+	failures := 0
+
+	for {
+		resp, err := d.sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(d.QueueURL),
+			MaxNumberOfMessages: d.MaxMessages,
+			WaitTimeSeconds:     d.WaitTimeSeconds,
+			VisibilityTimeout:   d.VisibilityTimeout,
+		})
+		d.observeOp(opReceive, err)
+		if err != nil {
+			failures++
+			logrus.Error(fmt.Errorf("Error receiving messages from SQS: %s", err))
+			time.Sleep(backoffDelay(failures))
+			continue
+		}
+		failures = 0
+
+		for _, msg := range resp.Messages {
+			objs, err := parseS3Event(*msg.Body)
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
+
+			for _, obj := range objs {
+				if d.alreadyProcessed(*obj.Key) {
+					logrus.WithField("object", *obj.Key).Info("Already processed, skipping")
+					continue
+				}
+
+				d.trackPending(*obj.Key, msg)
+				d.ObjectsToDownload <- obj
+			}
+		}
+	}
+}
+
+// ackDownloads drains DownloadedObjects, deleting the SQS message that
+// announced each object once it's confirmed downloaded, then forwards the
+// object on to Downloaded for the caller.
+func (d *EventDownloader) ackDownloads() {
+	ctx := context.Background()
+
+	for downloaded := range d.DownloadedObjects {
+		if msg, ok := d.takePending(downloaded.Object); ok {
+			if _, err := d.sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(d.QueueURL),
+				ReceiptHandle: msg.ReceiptHandle,
+			}); err != nil {
+				logrus.Error(fmt.Errorf("Error deleting SQS message: %s", err))
+			}
+		}
+
+		d.Downloaded <- downloaded
+	}
+}
+
+// Download starts the SQS consumer loop, the shared worker pool, and the
+// message-acknowledgment loop, returning the channel of downloaded objects,
+// satisfying the same contract as Downloader.Download.
+func (d *EventDownloader) Download() chan state.DownloadedObject {
+	go d.consumeQueue()
+	go d.downloadObjects()
+	go d.ackDownloads()
+	return d.Downloaded
+}
@@ -0,0 +1,74 @@
+package logbucket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseS3Event_Direct(t *testing.T) {
+	body := `{
+		"Records": [
+			{"s3": {"bucket": {"name": "my-bucket"}, "object": {"key": "AWSLogs/123/elasticloadbalancing/us-east-1/2020/01/02/123_elasticloadbalancing_us-east-1_my-lb.log.gz"}}}
+		]
+	}`
+
+	objs, err := parseS3Event(body)
+	if err != nil {
+		t.Fatalf("parseS3Event returned error: %s", err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("expected 1 object, got %d", len(objs))
+	}
+	want := "AWSLogs/123/elasticloadbalancing/us-east-1/2020/01/02/123_elasticloadbalancing_us-east-1_my-lb.log.gz"
+	if got := *objs[0].Key; got != want {
+		t.Errorf("Key = %q, want %q", got, want)
+	}
+}
+
+func TestParseS3Event_SNSEnvelope(t *testing.T) {
+	inner := `{"Records": [{"s3": {"bucket": {"name": "my-bucket"}, "object": {"key": "some/key.log.gz"}}}]}`
+	envelope := `{"Type": "Notification", "Message": ` + jsonString(inner) + `}`
+
+	objs, err := parseS3Event(envelope)
+	if err != nil {
+		t.Fatalf("parseS3Event returned error: %s", err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("expected 1 object, got %d", len(objs))
+	}
+	if got := *objs[0].Key; got != "some/key.log.gz" {
+		t.Errorf("Key = %q, want %q", got, "some/key.log.gz")
+	}
+}
+
+func TestParseS3Event_URLEncodedKey(t *testing.T) {
+	body := `{"Records": [{"s3": {"bucket": {"name": "my-bucket"}, "object": {"key": "some+prefix/file%3A1.log.gz"}}}]}`
+
+	objs, err := parseS3Event(body)
+	if err != nil {
+		t.Fatalf("parseS3Event returned error: %s", err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("expected 1 object, got %d", len(objs))
+	}
+	want := "some prefix/file:1.log.gz"
+	if got := *objs[0].Key; got != want {
+		t.Errorf("Key = %q, want %q", got, want)
+	}
+}
+
+func TestParseS3Event_InvalidJSON(t *testing.T) {
+	if _, err := parseS3Event("not json"); err == nil {
+		t.Fatal("expected an error for invalid JSON, got nil")
+	}
+}
+
+// jsonString quotes and escapes s the way encoding/json would, so it can be
+// embedded as a JSON string value inside a hand-written test fixture.
+func jsonString(s string) string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
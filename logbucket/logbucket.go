@@ -1,21 +1,32 @@
 package logbucket
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/honeycombio/honeyelb/meta"
 	"github.com/honeycombio/honeyelb/state"
 )
 
+// defaultObjectTimeout bounds how long a single object download attempt may
+// take. A timed-out attempt fails with a context deadline error, which
+// isRetryable does not treat as retryable, so the download is abandoned.
+const defaultObjectTimeout = 5 * time.Minute
+
+// errorsChanBuffer sizes Downloader.Errors so a burst of terminal failures
+// doesn't immediately block workers on callers that are slow to drain it.
+const errorsChanBuffer = 64
+
 const (
 	AWSElasticLoadBalancing     = "elasticloadbalancing"
 	AWSApplicationLoadBalancing = "elasticloadbalancingv2"
@@ -23,6 +34,18 @@ const (
 	AWSCloudTrail               = "cloudtrail"
 )
 
+// LogFormat identifies the on-disk shape of the objects a downloader
+// produces, so the caller layer can select the right parser without
+// sniffing file contents.
+type LogFormat string
+
+const (
+	LogFormatELB        LogFormat = "elb"
+	LogFormatALB        LogFormat = "alb"
+	LogFormatCloudFront LogFormat = "cloudfront"
+	LogFormatCloudTrail LogFormat = "cloudtrail"
+)
+
 type ObjectDownloader interface {
 	fmt.Stringer
 
@@ -33,27 +56,99 @@ type ObjectDownloader interface {
 	// Bucket will return the name of the bucket we are downloading the
 	// objects from
 	Bucket() string
+
+	// Format tells the caller which parser to use for the objects this
+	// downloader produces (e.g. CloudTrail's JSON gzip vs. ALB's
+	// space-separated text, which has a different field count than
+	// classic ELB).
+	Format() LogFormat
 }
 
 // Wrapper struct used to unite the specific structs with common methods.
 type Downloader struct {
 	state.Stater
 	ObjectDownloader
-	Sess              *session.Session
+	AWSConfig         aws.Config
 	DownloadedObjects chan state.DownloadedObject
-	ObjectsToDownload chan *s3.Object
+	ObjectsToDownload chan *types.Object
+
+	// EndpointOverride and S3ForcePathStyle target the S3 client at a
+	// non-AWS endpoint (MinIO, Ceph, LocalStack) for testing or non-AWS
+	// deployments. aws-sdk-go-v2 has no config-level endpoint field, so
+	// these are applied as S3 client options wherever the Downloader builds
+	// one.
+	EndpointOverride string
+	S3ForcePathStyle bool
+
+	// Errors receives terminal (non-retryable) download failures, once
+	// downloadObject has given up retrying. It's buffered (errorsChanBuffer)
+	// and sends to it never block a worker: once full, further errors are
+	// logged and dropped rather than stalling downloadObjects.
+	Errors chan error
+
+	// Concurrency is the number of worker goroutines draining
+	// ObjectsToDownload. Set via NewDownloader; defaults to 1.
+	Concurrency int
+
+	// ObjectTimeout bounds how long a single object download may run before
+	// it's treated as a failed attempt. Defaults to defaultObjectTimeout.
+	ObjectTimeout time.Duration
+
+	// BackfillWindow controls how far back from now an object's
+	// LastModified may be and still be downloaded during steady-state
+	// polling. Defaults to one hour.
+	BackfillWindow time.Duration
+
+	// HistoricalStart, if set, causes pollObjects to replay every day's
+	// prefix from HistoricalStart through today once at startup, before
+	// moving on to steady-state polling. Left zero, no historical replay is
+	// performed.
+	HistoricalStart time.Time
+
+	// Metrics, if set via SetMetrics, records S3 operation counts, errors,
+	// bytes, and download duration. Left nil, instrumentation is a no-op.
+	Metrics *Metrics
+}
+
+// SetMetrics attaches m to the Downloader so subsequent operations are
+// recorded against it.
+func (d *Downloader) SetMetrics(m *Metrics) {
+	d.Metrics = m
 }
 
-func NewDownloader(sess *session.Session, stater state.Stater, downloader ObjectDownloader) *Downloader {
+// NewDownloader builds a Downloader backed by concurrency worker goroutines.
+// A concurrency of 0 or less is treated as 1, preserving the old
+// single-goroutine behavior.
+func NewDownloader(cfg aws.Config, stater state.Stater, downloader ObjectDownloader, concurrency int) *Downloader {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
 	return &Downloader{
 		Stater:            stater,
 		ObjectDownloader:  downloader,
-		Sess:              sess,
+		AWSConfig:         cfg,
 		DownloadedObjects: make(chan state.DownloadedObject),
-		ObjectsToDownload: make(chan *s3.Object),
+		ObjectsToDownload: make(chan *types.Object),
+		Errors:            make(chan error, errorsChanBuffer),
+		Concurrency:       concurrency,
+		ObjectTimeout:     defaultObjectTimeout,
 	}
 }
 
+// s3Client builds an S3 client from AWSConfig, applying EndpointOverride and
+// S3ForcePathStyle if set.
+func (d *Downloader) s3Client() *s3.Client {
+	return s3.NewFromConfig(d.AWSConfig, func(o *s3.Options) {
+		if d.EndpointOverride != "" {
+			o.BaseEndpoint = aws.String(d.EndpointOverride)
+		}
+		if d.S3ForcePathStyle {
+			o.UsePathStyle = true
+		}
+	})
+}
+
 type ELBDownloader struct {
 	Prefix, BucketName, AccountID, Region, LBName string
 }
@@ -83,8 +178,12 @@ func (d *CloudFrontDownloader) Bucket() string {
 	return d.BucketName
 }
 
-func NewELBDownloader(sess *session.Session, bucketName, bucketPrefix, lbName string) *ELBDownloader {
-	metadata := meta.Data(sess)
+func (d *CloudFrontDownloader) Format() LogFormat {
+	return LogFormatCloudFront
+}
+
+func NewELBDownloader(cfg aws.Config, bucketName, bucketPrefix, lbName string) *ELBDownloader {
+	metadata := meta.Data(cfg)
 	return &ELBDownloader{
 		AccountID:  metadata.AccountID,
 		Region:     metadata.Region,
@@ -109,7 +208,11 @@ func (d *ELBDownloader) Bucket() string {
 	return d.BucketName
 }
 
-func (d *Downloader) downloadObject(obj *s3.Object) error {
+func (d *ELBDownloader) Format() LogFormat {
+	return LogFormatELB
+}
+
+func (d *Downloader) downloadObject(obj *types.Object) error {
 	logrus.WithFields(logrus.Fields{
 		"key":           *obj.Key,
 		"size":          *obj.Size,
@@ -122,15 +225,26 @@ func (d *Downloader) downloadObject(obj *s3.Object) error {
 		return fmt.Errorf("Error creating tmp file: %s", err)
 	}
 
-	downloader := s3manager.NewDownloader(d.Sess)
+	downloader := manager.NewDownloader(d.s3Client())
 
-	nBytes, err := downloader.Download(f, &s3.GetObjectInput{
-		Bucket: aws.String(d.Bucket()),
-		Key:    aws.String(*obj.Key),
+	start := time.Now()
+	var nBytes int64
+	err = withRetry(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), d.ObjectTimeout)
+		defer cancel()
+
+		n, dlErr := downloader.Download(ctx, f, &s3.GetObjectInput{
+			Bucket: aws.String(d.Bucket()),
+			Key:    aws.String(*obj.Key),
+		})
+		nBytes = n
+		return dlErr
 	})
+	d.observeOp(opDownload, err)
 	if err != nil {
 		return fmt.Errorf("Error downloading object file: %s", err)
 	}
+	d.observeDownload(nBytes, time.Since(start))
 
 	logrus.WithFields(logrus.Fields{
 		"bytes":  nBytes,
@@ -146,74 +260,173 @@ func (d *Downloader) downloadObject(obj *s3.Object) error {
 	return nil
 }
 
+// reportError pushes err onto Errors without blocking. If nothing is
+// draining Errors and it's already full, the error is logged and dropped
+// rather than stalling the worker that hit it.
+func (d *Downloader) reportError(err error) {
+	select {
+	case d.Errors <- err:
+	default:
+		logrus.WithError(err).Warn("Errors channel full, dropping terminal download error")
+	}
+}
+
 func (d *Downloader) downloadObjects() {
-	for obj := range d.ObjectsToDownload {
-		if err := d.downloadObject(obj); err != nil {
-			logrus.Error(err)
-		}
+	var wg sync.WaitGroup
+
+	for i := 0; i < d.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range d.ObjectsToDownload {
+				if err := d.downloadObject(obj); err != nil {
+					logrus.Error(err)
+					d.reportError(err)
+				}
+			}
+		}()
 	}
+
+	wg.Wait()
 }
 
-func (d *Downloader) accessLogBucketPageCallback(processedObjects []string, bucketResp *s3.ListObjectsOutput, lastPage bool) bool {
-	// TODO: This sort doesn't work as originally intended if the paging
-	// comes into play. Consider removing, or gathering all desired objects
-	// as a result of the callback, _then_ sorting and iterating over them.
-	sort.Slice(bucketResp.Contents, func(i, j int) bool {
-		return (*bucketResp.Contents[i].LastModified).After(
-			*bucketResp.Contents[j].LastModified,
-		)
-	})
+// backfillWindow returns the configured BackfillWindow, defaulting to one
+// hour.
+func (d *Downloader) backfillWindow() time.Duration {
+	if d.BackfillWindow <= 0 {
+		return time.Hour
+	}
+	return d.BackfillWindow
+}
 
-	for _, obj := range bucketResp.Contents {
+// accessLogBucketPageCallback filters a single page of listed objects down
+// to ones that haven't already been processed, appending the survivors to
+// objs. When windowed is true (steady-state polling) objects older than the
+// backfill window are dropped too; historical replay passes windowed=false
+// since the whole point is to fetch objects far outside that window.
+// Sorting happens once, after every page has been gathered, rather than
+// per-page, so ordering guarantees hold under pagination.
+func (d *Downloader) accessLogBucketPageCallback(processedObjects []string, contents []types.Object, objs *[]types.Object, windowed, lastPage bool) bool {
+	for i := range contents {
+		obj := contents[i]
+		alreadyProcessed := false
 		for _, processedObj := range processedObjects {
 			if *obj.Key == processedObj {
 				logrus.WithField("object", processedObj).Info("Already processed, skipping")
-				return true
+				alreadyProcessed = true
+				break
 			}
 		}
+		if alreadyProcessed {
+			continue
+		}
 
-		// Backfill one hour backwards by default
-		//
-		// TODO(nathanleclaire): Make backfill interval configurable.
-		if time.Since(*obj.LastModified) < time.Hour {
-			d.ObjectsToDownload <- obj
+		if !windowed || time.Since(*obj.LastModified) < d.backfillWindow() {
+			*objs = append(*objs, obj)
 		}
 	}
 
 	return !lastPage
 }
-func (d *Downloader) pollObjects() {
-	// get new logs every 5 minutes
-	ticker := time.NewTicker(5 * time.Minute).C
 
-	s3svc := s3.New(d.Sess, nil)
+// listPrefix lists every object under prefix, across all pages, filters out
+// already-processed objects (and, if windowed, objects older than the
+// backfill window), and returns the survivors sorted newest-first.
+func (d *Downloader) listPrefix(ctx context.Context, s3Client *s3.Client, prefix string, windowed bool) ([]types.Object, error) {
+	processedObjects, err := d.ProcessedObjects()
+	if err != nil {
+		logrus.Error(err)
+	}
 
-	// For now, get objects for just today.
-	totalPrefix := d.ObjectPrefix(time.Now().UTC())
+	var objs []types.Object
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(d.Bucket()),
+		Prefix: aws.String(prefix),
+	})
 
-	// Start the loop to continually ingest access logs.
-	for {
+	for paginator.HasMorePages() {
+		page, pageErr := paginator.NextPage(ctx)
+		d.observeOp(opList, pageErr)
+		if pageErr != nil {
+			return nil, pageErr
+		}
+		d.accessLogBucketPageCallback(processedObjects, page.Contents, &objs, windowed, !paginator.HasMorePages())
+	}
+
+	sort.Slice(objs, func(i, j int) bool {
+		return (*objs[i].LastModified).After(*objs[j].LastModified)
+	})
+
+	return objs, nil
+}
+
+func (d *Downloader) dispatch(objs []types.Object) {
+	for i := range objs {
+		obj := objs[i]
+		d.ObjectsToDownload <- &obj
+	}
+}
+
+// backfillHistory replays every day's prefix from HistoricalStart through
+// today, once, before steady-state polling begins. It's a no-op unless
+// HistoricalStart has been set.
+func (d *Downloader) backfillHistory(ctx context.Context, s3Client *s3.Client) {
+	if d.HistoricalStart.IsZero() {
+		return
+	}
+
+	now := time.Now().UTC()
+	for day := d.HistoricalStart.UTC().Truncate(24 * time.Hour); !day.After(now); day = day.Add(24 * time.Hour) {
+		prefix := d.ObjectPrefix(day)
 		logrus.WithFields(logrus.Fields{
-			"prefix": totalPrefix,
+			"prefix": prefix,
 			"entity": d.String(),
-		}).Info("Getting recent objects")
+		}).Info("Backfilling historical objects")
 
-		processedObjects, err := d.ProcessedObjects()
+		objs, err := d.listPrefix(ctx, s3Client, prefix, false)
 		if err != nil {
-			logrus.Error(err)
+			logrus.Error(fmt.Errorf("Error listing/paging historical bucket objects: %s", err))
+			continue
 		}
+		d.dispatch(objs)
+	}
+}
+
+func (d *Downloader) pollObjects() {
+	// get new logs every 5 minutes
+	ticker := time.NewTicker(5 * time.Minute).C
 
-		cb := func(bucketResp *s3.ListObjectsOutput, lastPage bool) bool {
-			return d.accessLogBucketPageCallback(processedObjects, bucketResp, lastPage)
+	ctx := context.Background()
+	s3Client := d.s3Client()
+
+	d.backfillHistory(ctx, s3Client)
+
+	// Start the loop to continually ingest access logs. Each tick covers
+	// both today's prefix and the prior hour's, so a restart a few minutes
+	// past midnight UTC still picks up yesterday's tail. Most ticks those
+	// two prefixes are identical (same day), so we list each distinct
+	// prefix only once.
+	for {
+		now := time.Now().UTC()
+		prefixes := []string{d.ObjectPrefix(now)}
+		if hourAgoPrefix := d.ObjectPrefix(now.Add(-1 * time.Hour)); hourAgoPrefix != prefixes[0] {
+			prefixes = append(prefixes, hourAgoPrefix)
 		}
 
-		if err := s3svc.ListObjectsPages(&s3.ListObjectsInput{
-			Bucket: aws.String(d.Bucket()),
-			Prefix: aws.String(totalPrefix),
-		}, cb); err != nil {
-			fmt.Fprintln(os.Stderr, "Error listing/paging bucket objects: ", err)
-			os.Exit(1)
+		for _, prefix := range prefixes {
+			logrus.WithFields(logrus.Fields{
+				"prefix": prefix,
+				"entity": d.String(),
+			}).Info("Getting recent objects")
+
+			objs, err := d.listPrefix(ctx, s3Client, prefix, true)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error listing/paging bucket objects: ", err)
+				os.Exit(1)
+			}
+			d.dispatch(objs)
 		}
+
 		logrus.Info("Pausing until the next set of logs are available")
 		<-ticker
 	}
@@ -223,4 +436,4 @@ func (d *Downloader) Download() chan state.DownloadedObject {
 	go d.pollObjects()
 	go d.downloadObjects()
 	return d.DownloadedObjects
-}
\ No newline at end of file
+}
@@ -0,0 +1,126 @@
+package logbucket
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func fakeObject(key string, age time.Duration) types.Object {
+	return types.Object{
+		Key:          aws.String(key),
+		LastModified: aws.Time(time.Now().Add(-age)),
+	}
+}
+
+func TestAccessLogBucketPageCallback_Windowed(t *testing.T) {
+	d := &Downloader{BackfillWindow: time.Hour}
+
+	contents := []types.Object{
+		fakeObject("recent", 5*time.Minute),
+		fakeObject("stale", 2*time.Hour),
+		fakeObject("already-processed", 5*time.Minute),
+	}
+
+	var objs []types.Object
+	more := d.accessLogBucketPageCallback([]string{"already-processed"}, contents, &objs, true, true)
+
+	if more {
+		t.Error("expected more=false on the last page")
+	}
+
+	var gotKeys []string
+	for _, obj := range objs {
+		gotKeys = append(gotKeys, *obj.Key)
+	}
+	want := []string{"recent"}
+	if len(gotKeys) != len(want) || gotKeys[0] != want[0] {
+		t.Errorf("windowed callback kept %v, want %v (stale object outside BackfillWindow, already-processed object should both be dropped)", gotKeys, want)
+	}
+}
+
+func TestAccessLogBucketPageCallback_Unwindowed(t *testing.T) {
+	d := &Downloader{BackfillWindow: time.Hour}
+
+	contents := []types.Object{
+		fakeObject("recent", 5*time.Minute),
+		fakeObject("stale", 30*24*time.Hour),
+		fakeObject("already-processed", 5*time.Minute),
+	}
+
+	var objs []types.Object
+	d.accessLogBucketPageCallback([]string{"already-processed"}, contents, &objs, false, true)
+
+	var gotKeys []string
+	for _, obj := range objs {
+		gotKeys = append(gotKeys, *obj.Key)
+	}
+	want := []string{"recent", "stale"}
+	if len(gotKeys) != len(want) {
+		t.Fatalf("unwindowed callback kept %v, want %v (historical replay must not drop objects outside BackfillWindow)", gotKeys, want)
+	}
+	for i, k := range want {
+		if gotKeys[i] != k {
+			t.Errorf("gotKeys[%d] = %q, want %q", i, gotKeys[i], k)
+		}
+	}
+}
+
+func TestAccessLogBucketPageCallback_MorePages(t *testing.T) {
+	d := &Downloader{}
+	var objs []types.Object
+
+	if more := d.accessLogBucketPageCallback(nil, nil, &objs, true, false); !more {
+		t.Error("expected more=true when lastPage=false")
+	}
+	if more := d.accessLogBucketPageCallback(nil, nil, &objs, true, true); more {
+		t.Error("expected more=false when lastPage=true")
+	}
+}
+
+// TestCrossPageSort exercises the same collect-then-sort-once sequence
+// listPrefix runs across ListObjectsV2 pages: gather every page's
+// survivors into one slice via accessLogBucketPageCallback, then sort once
+// at the end, rather than sorting per page (which would only guarantee
+// order within a page, not across them).
+func TestCrossPageSort(t *testing.T) {
+	d := &Downloader{}
+
+	page1 := []types.Object{fakeObject("oldest", 3*time.Hour)}
+	page2 := []types.Object{fakeObject("newest", 1*time.Minute), fakeObject("middle", time.Hour)}
+
+	var objs []types.Object
+	d.accessLogBucketPageCallback(nil, page1, &objs, false, false)
+	d.accessLogBucketPageCallback(nil, page2, &objs, false, true)
+
+	sort.Slice(objs, func(i, j int) bool {
+		return (*objs[i].LastModified).After(*objs[j].LastModified)
+	})
+
+	want := []string{"newest", "middle", "oldest"}
+	if len(objs) != len(want) {
+		t.Fatalf("got %d objects, want %d", len(objs), len(want))
+	}
+	for i, k := range want {
+		if *objs[i].Key != k {
+			t.Errorf("objs[%d] = %q, want %q (not sorted newest-first across pages)", i, *objs[i].Key, k)
+		}
+	}
+}
+
+func TestBackfillWindow_DefaultsToOneHour(t *testing.T) {
+	d := &Downloader{}
+	if got := d.backfillWindow(); got != time.Hour {
+		t.Errorf("backfillWindow() = %v, want %v", got, time.Hour)
+	}
+}
+
+func TestBackfillWindow_Configured(t *testing.T) {
+	d := &Downloader{BackfillWindow: 15 * time.Minute}
+	if got := d.backfillWindow(); got != 15*time.Minute {
+		t.Errorf("backfillWindow() = %v, want %v", got, 15*time.Minute)
+	}
+}
@@ -0,0 +1,80 @@
+package logbucket
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors shared by every Downloader, so
+// that S3 list/download activity across all configured entities shows up on
+// a single set of metrics labeled by entity, bucket, and operation.
+type Metrics struct {
+	OpsTotal        *prometheus.CounterVec
+	ErrorsTotal     *prometheus.CounterVec
+	BytesTotal      *prometheus.CounterVec
+	DownloadSeconds *prometheus.HistogramVec
+}
+
+// NewMetrics builds the Metrics collectors. Call RegisterMetrics to attach
+// them to a Registerer before use.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		OpsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "s3_ops_total",
+			Help: "Total number of S3 operations performed, labeled by entity, bucket, and operation.",
+		}, []string{"entity", "bucket", "operation"}),
+		ErrorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "s3_errors_total",
+			Help: "Total number of S3 operation errors, labeled by entity, bucket, and operation.",
+		}, []string{"entity", "bucket", "operation"}),
+		BytesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "s3_bytes_total",
+			Help: "Total number of bytes downloaded from S3, labeled by entity, bucket, and operation.",
+		}, []string{"entity", "bucket", "operation"}),
+		DownloadSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "s3_download_duration_seconds",
+			Help: "Time spent downloading an object from S3, labeled by entity, bucket, and operation.",
+		}, []string{"entity", "bucket", "operation"}),
+	}
+}
+
+// RegisterMetrics registers m's collectors with reg, so the honeyelb main
+// can attach them to its HTTP handler.
+func RegisterMetrics(reg prometheus.Registerer, m *Metrics) error {
+	for _, c := range []prometheus.Collector{
+		m.OpsTotal,
+		m.ErrorsTotal,
+		m.BytesTotal,
+		m.DownloadSeconds,
+	} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	opList     = "list"
+	opDownload = "download"
+	opReceive  = "receive"
+)
+
+func (d *Downloader) observeOp(op string, err error) {
+	if d.Metrics == nil {
+		return
+	}
+	d.Metrics.OpsTotal.WithLabelValues(d.String(), d.Bucket(), op).Inc()
+	if err != nil {
+		d.Metrics.ErrorsTotal.WithLabelValues(d.String(), d.Bucket(), op).Inc()
+	}
+}
+
+func (d *Downloader) observeDownload(nBytes int64, duration time.Duration) {
+	if d.Metrics == nil {
+		return
+	}
+	d.Metrics.BytesTotal.WithLabelValues(d.String(), d.Bucket(), opDownload).Add(float64(nBytes))
+	d.Metrics.DownloadSeconds.WithLabelValues(d.String(), d.Bucket(), opDownload).Observe(duration.Seconds())
+}
@@ -0,0 +1,57 @@
+package logbucket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveOp_NilMetricsIsNoop(t *testing.T) {
+	d := &Downloader{ObjectDownloader: &ELBDownloader{BucketName: "my-bucket", LBName: "my-lb"}}
+	d.observeOp(opList, nil)
+	d.observeOp(opList, errors.New("boom"))
+}
+
+func TestObserveDownload_NilMetricsIsNoop(t *testing.T) {
+	d := &Downloader{ObjectDownloader: &ELBDownloader{BucketName: "my-bucket", LBName: "my-lb"}}
+	d.observeDownload(1024, time.Second)
+}
+
+func TestObserveOp_RecordsEntityBucketOperationLabels(t *testing.T) {
+	m := NewMetrics()
+	d := &Downloader{
+		ObjectDownloader: &ELBDownloader{BucketName: "my-bucket", LBName: "my-lb"},
+		Metrics:          m,
+	}
+
+	d.observeOp(opList, nil)
+	d.observeOp(opList, errors.New("boom"))
+
+	if got := testutil.ToFloat64(m.OpsTotal.WithLabelValues("my-lb", "my-bucket", opList)); got != 2 {
+		t.Errorf("OpsTotal{entity=my-lb,bucket=my-bucket,operation=list} = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.ErrorsTotal.WithLabelValues("my-lb", "my-bucket", opList)); got != 1 {
+		t.Errorf("ErrorsTotal{entity=my-lb,bucket=my-bucket,operation=list} = %v, want 1", got)
+	}
+}
+
+func TestObserveDownload_RecordsBytesAndDuration(t *testing.T) {
+	m := NewMetrics()
+	d := &Downloader{
+		ObjectDownloader: &ELBDownloader{BucketName: "my-bucket", LBName: "my-lb"},
+		Metrics:          m,
+	}
+
+	d.observeDownload(1024, 2*time.Second)
+
+	if got := testutil.ToFloat64(m.BytesTotal.WithLabelValues("my-lb", "my-bucket", opDownload)); got != 1024 {
+		t.Errorf("BytesTotal{entity=my-lb,bucket=my-bucket,operation=download} = %v, want 1024", got)
+	}
+
+	series := testutil.CollectAndCount(m.DownloadSeconds)
+	if series != 1 {
+		t.Errorf("DownloadSeconds series count = %d, want 1", series)
+	}
+}
@@ -0,0 +1,96 @@
+package logbucket
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	retryBaseDelay   = 500 * time.Millisecond
+	retryFactor      = 2
+	retryCapDelay    = 30 * time.Second
+	retryMaxAttempts = 5
+)
+
+// terminalErrorCodes are S3 error codes that will never succeed on retry, so
+// we fail fast instead of burning the attempt budget.
+var terminalErrorCodes = map[string]bool{
+	"NoSuchKey":    true,
+	"AccessDenied": true,
+}
+
+// isRetryable reports whether err represents a transient condition (request
+// throttling, a 5xx response, a request timeout, or a reset connection) that
+// is worth retrying, as opposed to a terminal error like NoSuchKey or
+// AccessDenied.
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if terminalErrorCodes[apiErr.ErrorCode()] {
+			return false
+		}
+		switch apiErr.ErrorCode() {
+		case "RequestTimeout", "RequestTimeoutException", "Throttling", "ThrottlingException",
+			"TooManyRequestsException", "RequestLimitExceeded", "ProvisionedThroughputExceededException":
+			return true
+		}
+	}
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() >= 500 {
+		return true
+	}
+
+	msg := err.Error()
+	if strings.Contains(msg, "connection reset") || strings.Contains(msg, "EOF") {
+		return true
+	}
+
+	return false
+}
+
+// backoffDelay returns the delay to wait before retry attempt n (1-indexed),
+// as an exponential backoff from retryBaseDelay with factor retryFactor,
+// capped at retryCapDelay, with full jitter: the result is a uniformly random
+// duration between 0 and that capped value.
+func backoffDelay(attempt int) time.Duration {
+	delay := float64(retryBaseDelay) * pow(retryFactor, attempt-1)
+	if delay > float64(retryCapDelay) {
+		delay = float64(retryCapDelay)
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+func pow(base float64, exp int) float64 {
+	result := 1.0
+	for i := 0; i < exp; i++ {
+		result *= base
+	}
+	return result
+}
+
+// withRetry calls fn up to retryMaxAttempts times, backing off between
+// retryable failures, and returns as soon as fn succeeds or a terminal error
+// is encountered.
+func withRetry(fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= retryMaxAttempts; attempt++ {
+		err = fn()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		if attempt < retryMaxAttempts {
+			time.Sleep(backoffDelay(attempt))
+		}
+	}
+	return err
+}
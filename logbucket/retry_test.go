@@ -0,0 +1,90 @@
+package logbucket
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+type fakeAPIError struct {
+	code string
+}
+
+func (e *fakeAPIError) Error() string                 { return e.code }
+func (e *fakeAPIError) ErrorCode() string             { return e.code }
+func (e *fakeAPIError) ErrorMessage() string          { return e.code }
+func (e *fakeAPIError) ErrorFault() smithy.ErrorFault { return smithy.FaultUnknown }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil error", nil, false},
+		{"terminal NoSuchKey", &fakeAPIError{code: "NoSuchKey"}, false},
+		{"terminal AccessDenied", &fakeAPIError{code: "AccessDenied"}, false},
+		{"throttling", &fakeAPIError{code: "Throttling"}, true},
+		{"request timeout", &fakeAPIError{code: "RequestTimeout"}, true},
+		{"unrecognized API error", &fakeAPIError{code: "SomethingElse"}, false},
+		{"5xx response", &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 503}}}, true},
+		{"4xx response", &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 404}}}, false},
+		{"connection reset", errors.New("read: connection reset by peer"), true},
+		{"EOF", errors.New("unexpected EOF"), true},
+		{"plain error", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	for attempt := 1; attempt <= retryMaxAttempts; attempt++ {
+		delay := backoffDelay(attempt)
+		if delay < 0 {
+			t.Errorf("backoffDelay(%d) = %v, want >= 0", attempt, delay)
+		}
+		if delay > retryCapDelay {
+			t.Errorf("backoffDelay(%d) = %v, want <= %v", attempt, delay, retryCapDelay)
+		}
+	}
+}
+
+func TestWithRetry_SucceedsAfterTransientFailures(t *testing.T) {
+	attempts := 0
+	err := withRetry(func() error {
+		attempts++
+		if attempts < 3 {
+			return &fakeAPIError{code: "Throttling"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRetry returned error: %s", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestWithRetry_StopsOnTerminalError(t *testing.T) {
+	attempts := 0
+	err := withRetry(func() error {
+		attempts++
+		return &fakeAPIError{code: "NoSuchKey"}
+	})
+	if err == nil {
+		t.Fatal("expected a terminal error, got nil")
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 (no retries on terminal error)", attempts)
+	}
+}
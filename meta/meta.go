@@ -0,0 +1,39 @@
+// Package meta resolves the AWS account ID and region a Downloader is
+// running under, so per-entity ObjectPrefix builders don't need their own
+// copy of that lookup.
+package meta
+
+import (
+	"context"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Metadata is the account/region pair each ObjectDownloader constructor
+// bakes into its generated S3 prefix.
+type Metadata struct {
+	AccountID string
+	Region    string
+}
+
+// Data resolves Metadata from cfg: Region comes straight off the config,
+// AccountID via STS GetCallerIdentity (which works for any credential
+// source cfg was built with - static keys, a profile, an assumed role, or
+// the EC2/ECS instance role). If the STS call fails, AccountID is left
+// empty and the error is logged rather than returned, since callers build
+// prefixes from this best-effort and a missing account ID should not be
+// fatal at startup.
+func Data(cfg aws.Config) Metadata {
+	md := Metadata{Region: cfg.Region}
+
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		logrus.WithError(err).Error("Error fetching account ID via STS GetCallerIdentity")
+		return md
+	}
+
+	md.AccountID = aws.ToString(identity.Account)
+	return md
+}